@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	rtctokenbuilder "github.com/AgoraIO/Tools/DynamicKey/AgoraDynamicKey/go/src/rtctokenbuilder2"
+)
+
+// 入会凭证签发依赖下列环境变量，按 rtcRouter 当前配置的后端分别生效：
+//
+//	TRTC_SDKAPPID, TRTC_SECRET     —— 配置了 RTC_TRTC 后端时必需
+//	AGORA_APP_ID, AGORA_APP_CERT   —— 配置了 RTC_AGORA 后端时必需
+//
+// main.go 的 init() 会在启动阶段针对已配置的后端校验这些变量，缺失时直接
+// log.Fatalf 退出，而不是留到第一次 /room/create 请求才发现签发失败。
+// 本地开发或跑测试、又没有真实密钥时可以设置 RTC_CREDENTIAL_STUB=1，
+// issueCredential 会跳过真实签名，直接返回一个不可用于生产的占位凭证。
+const credentialStubEnv = "RTC_CREDENTIAL_STUB"
+
+// Credential 是返回给客户端、可直接交给 RTC SDK 使用的入会凭证。
+type Credential struct {
+	SDKAppID int64  `json:"sdkappid,omitempty"`
+	UserSig  string `json:"usersig,omitempty"`
+	AppID    string `json:"app_id,omitempty"`
+	Token    string `json:"token,omitempty"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+type RefreshTokenRequest struct {
+	RoomID string `json:"room_id"`
+}
+
+// defaultTokenTTL 是未配置 TTL 时的默认有效期（与 UserSig 的 expire 字段单位一致，秒）。
+const defaultTokenTTL = 24 * time.Hour
+
+func tokenTTL() time.Duration {
+	if v := os.Getenv("RTC_TOKEN_TTL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return defaultTokenTTL
+}
+
+// issueCredential 根据房间选中的 RTC 后端计算对应的入会凭证。
+func issueCredential(rtc RTCType, roomID, userID string) (Credential, error) {
+	ttl := tokenTTL()
+	if os.Getenv(credentialStubEnv) == "1" {
+		return issueStubCredential(rtc, ttl), nil
+	}
+	switch rtc {
+	case RTC_TRTC:
+		return issueTRTCCredential(userID, ttl)
+	case RTC_AGORA:
+		return issueAgoraCredential(roomID, userID, ttl)
+	default:
+		return Credential{}, fmt.Errorf("credentials: unsupported rtc type %q", rtc)
+	}
+}
+
+// issueStubCredential 在 RTC_CREDENTIAL_STUB=1 时返回一个不做真实签名的占位
+// 凭证，供本地开发和测试在没有 TRTC/Agora 密钥的情况下跑通 /room/create。
+func issueStubCredential(rtc RTCType, ttl time.Duration) Credential {
+	expireAt := time.Now().Unix() + int64(ttl/time.Second)
+	switch rtc {
+	case RTC_AGORA:
+		return Credential{AppID: "stub", Token: "stub-token", ExpireAt: expireAt}
+	default:
+		return Credential{SDKAppID: 0, UserSig: "stub-usersig", ExpireAt: expireAt}
+	}
+}
+
+// validateCredentialEnv 校验 rtcRouter 当前配置引用到的 RTC 后端所需的环境
+// 变量是否齐全，供 main.go 在启动阶段调用以尽早暴露配置缺失。
+// RTC_CREDENTIAL_STUB=1 时跳过校验；跑 `go test` 时（testing.Testing()）也
+// 跳过，避免单元测试环境里因为没有真实密钥就在 init() 阶段直接退出。
+func validateCredentialEnv(backends []RTCBackend) error {
+	if os.Getenv(credentialStubEnv) == "1" || testing.Testing() {
+		return nil
+	}
+
+	configured := make(map[RTCType]bool, len(backends))
+	for _, b := range backends {
+		configured[b.Name] = true
+	}
+
+	var missing []string
+	if configured[RTC_TRTC] {
+		if os.Getenv("TRTC_SDKAPPID") == "" {
+			missing = append(missing, "TRTC_SDKAPPID")
+		}
+		if os.Getenv("TRTC_SECRET") == "" {
+			missing = append(missing, "TRTC_SECRET")
+		}
+	}
+	if configured[RTC_AGORA] {
+		if os.Getenv("AGORA_APP_ID") == "" {
+			missing = append(missing, "AGORA_APP_ID")
+		}
+		if os.Getenv("AGORA_APP_CERT") == "" {
+			missing = append(missing, "AGORA_APP_CERT")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("credentials: missing required env vars for configured RTC backends: %s (set %s=1 to bypass for local/dev)", strings.Join(missing, ", "), credentialStubEnv)
+	}
+	return nil
+}
+
+// issueTRTCCredential 按腾讯云 TRTC 的 UserSig 算法签发凭证：
+// HMAC-SHA256 覆盖 sdkappid|identifier|expire|current_time|base64_random，
+// 再将原文 zlib 压缩后做 base64url 编码，得到标准 UserSig 字符串。
+func issueTRTCCredential(userID string, ttl time.Duration) (Credential, error) {
+	sdkAppID, err := strconv.ParseInt(os.Getenv("TRTC_SDKAPPID"), 10, 64)
+	if err != nil {
+		return Credential{}, fmt.Errorf("credentials: invalid TRTC_SDKAPPID: %w", err)
+	}
+	secret := os.Getenv("TRTC_SECRET")
+	if secret == "" {
+		return Credential{}, fmt.Errorf("credentials: TRTC_SECRET is not configured")
+	}
+
+	now := time.Now().Unix()
+	expire := int64(ttl / time.Second)
+
+	randomBuf := make([]byte, 4)
+	if _, err := rand.Read(randomBuf); err != nil {
+		return Credential{}, fmt.Errorf("credentials: failed to generate random: %w", err)
+	}
+	base64Random := base64.StdEncoding.EncodeToString(randomBuf)
+
+	sig := trtcHmacSign(secret, sdkAppID, userID, now, expire, base64Random)
+
+	doc := map[string]interface{}{
+		"TLS.ver":        "2.0",
+		"TLS.identifier": userID,
+		"TLS.sdkappid":   sdkAppID,
+		"TLS.expire":     expire,
+		"TLS.time":       now,
+		"TLS.random":     base64Random,
+		"TLS.sig":        sig,
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	userSig, err := zlibCompressToBase64URL(raw)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		SDKAppID: sdkAppID,
+		UserSig:  userSig,
+		ExpireAt: now + expire,
+	}, nil
+}
+
+// trtcHmacSign 对 sdkappid|identifier|expire|current_time|base64_random 做 HMAC-SHA256 签名。
+func trtcHmacSign(secret string, sdkAppID int64, userID string, now, expire int64, base64Random string) string {
+	content := fmt.Sprintf("%d|%s|%d|%d|%s", sdkAppID, userID, expire, now, base64Random)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func zlibCompressToBase64URL(raw []byte) (string, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+	// TRTC 的 UserSig 用自定义表做 URL 安全替换："+" -> "*"，"/" -> "-"，"=" -> "_"
+	replacer := strings.NewReplacer("+", "*", "/", "-", "=", "_")
+	return replacer.Replace(encoded), nil
+}
+
+// issueAgoraCredential 使用 AccessToken2 为指定 channel 签发 token，
+// 固定以 RolePublisher 角色签发（JoinChannel 加发布权限）；没有按调用方
+// 选择只加入、不发布的选项。
+func issueAgoraCredential(roomID, userID string, ttl time.Duration) (Credential, error) {
+	appID := os.Getenv("AGORA_APP_ID")
+	appCert := os.Getenv("AGORA_APP_CERT")
+	if appID == "" || appCert == "" {
+		return Credential{}, fmt.Errorf("credentials: AGORA_APP_ID/AGORA_APP_CERT are not configured")
+	}
+
+	expireSeconds := uint32(ttl / time.Second)
+	token, err := rtctokenbuilder.BuildTokenWithUserAccount(
+		appID, appCert, roomID, userID,
+		rtctokenbuilder.RolePublisher,
+		expireSeconds, expireSeconds,
+	)
+	if err != nil {
+		return Credential{}, fmt.Errorf("credentials: build agora token: %w", err)
+	}
+
+	return Credential{
+		AppID:    appID,
+		Token:    token,
+		ExpireAt: time.Now().Unix() + int64(expireSeconds),
+	}, nil
+}
+
+// refreshTokenHandler 处理 POST /room/refresh_token：为已存在的房间重新签发
+// join 凭证，而不重新分配底层 RTC 后端。
+func refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	room, ok, err := store.Get(r.Context(), req.RoomID)
+	if err != nil {
+		http.Error(w, "failed to query room", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	cred, err := issueCredential(room.RTCType, room.RoomID, room.OwnerUserID)
+	if err != nil {
+		http.Error(w, "failed to issue credential", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cred)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestTRTCHmacSign 是 trtcHmacSign 的黄金值测试：固定 secret/sdkappid/identifier/
+// expire/time/random 输入，校验 HMAC-SHA256 签名输出，防止签名内容顺序
+// （sdkappid|identifier|expire|time|random）被意外改动而破坏与 TRTC 的兼容性。
+func TestTRTCHmacSign(t *testing.T) {
+	got := trtcHmacSign("test-secret", 1400000000, "alice", 1700000000, 86400, "AAAAAA==")
+	want := "XosttIOQP22P6pQJamJ+hUIFPWCwEYTIcAYU1mw0XNc="
+	if got != want {
+		t.Fatalf("trtcHmacSign() = %q, want %q", got, want)
+	}
+}
+
+// TestZlibCompressToBase64URL 校验 UserSig 的 zlib+base64url 编码：
+// 输出只能使用 TRTC 的自定义 URL 安全字符表（* - _ 代替 + / =），
+// 且编码内容需要能还原出原始输入。
+func TestZlibCompressToBase64URL(t *testing.T) {
+	raw := []byte(`{"TLS.ver":"2.0","TLS.identifier":"alice","TLS.sdkappid":1400000000}`)
+
+	encoded, err := zlibCompressToBase64URL(raw)
+	if err != nil {
+		t.Fatalf("zlibCompressToBase64URL() error = %v", err)
+	}
+
+	for _, c := range encoded {
+		if c == '+' || c == '/' || c == '=' {
+			t.Fatalf("encoded UserSig %q contains non-URL-safe character %q", encoded, c)
+		}
+	}
+
+	decoded, err := decodeUserSig(encoded)
+	if err != nil {
+		t.Fatalf("failed to round-trip encoded UserSig: %v", err)
+	}
+	if string(decoded) != string(raw) {
+		t.Fatalf("round-tripped UserSig = %q, want %q", decoded, raw)
+	}
+}
+
+// decodeUserSig 是 zlibCompressToBase64URL 的逆操作，仅供本测试文件
+// 验证编码结果可以正确还原。
+func decodeUserSig(encoded string) ([]byte, error) {
+	replacer := strings.NewReplacer("*", "+", "-", "/", "_", "=")
+	raw, err := base64.StdEncoding.DecodeString(replacer.Replace(encoded))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MessageType 枚举信令通道上支持的消息种类。
+type MessageType string
+
+const (
+	MsgJoin    MessageType = "join"
+	MsgLeave   MessageType = "leave"
+	MsgChat    MessageType = "chat"
+	MsgDanmaku MessageType = "danmaku"
+	MsgSignal  MessageType = "signal" // SDP/ICE 转发
+)
+
+// Message 是客户端与服务端之间交换的信令/聊天消息。
+type Message struct {
+	Type    MessageType     `json:"type"`
+	RoomID  string          `json:"room_id"`
+	UserID  string          `json:"userid"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// Client 是加入某个房间 Hub 的一条 WebSocket 连接。
+type Client struct {
+	hub    *Hub
+	roomID string
+	userID string
+	conn   *websocket.Conn
+	send   chan Message
+}
+
+// Hub 维护一个房间内所有在线连接，并在它们之间广播消息。
+type Hub struct {
+	roomID    string
+	mu        sync.RWMutex
+	clients   map[*Client]struct{}
+	broadcast chan Message
+	done      chan struct{}
+	closed    bool // 房间销毁后置位，阻止升级竞态下的 join 写入已拆除的 clients
+}
+
+// HubManager 按 room_id 管理所有活跃的 Hub。
+type HubManager struct {
+	mu   sync.RWMutex
+	hubs map[string]*Hub
+}
+
+var hubManager = &HubManager{hubs: make(map[string]*Hub)}
+
+func (m *HubManager) getOrCreate(roomID string) *Hub {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok := m.hubs[roomID]; ok {
+		return h
+	}
+	h := newHub(roomID)
+	m.hubs[roomID] = h
+	go h.run()
+	return h
+}
+
+func (m *HubManager) get(roomID string) (*Hub, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.hubs[roomID]
+	return h, ok
+}
+
+// closeRoom 在房间被销毁时调用：广播一条 leave 类型的生命周期事件并关闭 Hub。
+func (m *HubManager) closeRoom(roomID string) {
+	m.mu.Lock()
+	h, ok := m.hubs[roomID]
+	if ok {
+		delete(m.hubs, roomID)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	h.deliver(Message{Type: MsgLeave, RoomID: roomID, Payload: json.RawMessage(`{"reason":"room_destroyed"}`)})
+	h.shutdown()
+}
+
+// shutdown 在持有锁的情况下将 Hub 标记为已关闭并断开所有客户端，
+// 之后再停掉 run() 的事件循环，避免 join/leave/deliver 在此之后写入一个已被置空的 clients。
+func (h *Hub) shutdown() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	for c := range h.clients {
+		close(c.send)
+	}
+	h.clients = nil
+	h.mu.Unlock()
+	close(h.done)
+}
+
+func newHub(roomID string) *Hub {
+	return &Hub{
+		roomID:    roomID,
+		clients:   make(map[*Client]struct{}),
+		broadcast: make(chan Message, 64),
+		done:      make(chan struct{}),
+	}
+}
+
+func (h *Hub) run() {
+	for {
+		select {
+		case msg := <-h.broadcast:
+			h.deliver(msg)
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *Hub) deliver(msg Message) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.closed {
+		return
+	}
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			// 客户端发送队列已满，跳过这一条避免阻塞整个 Hub。
+		}
+	}
+}
+
+// broadcastLocked 将消息直接送入广播通道，供房间生命周期事件调用。
+func (h *Hub) broadcastLocked(msg Message) {
+	select {
+	case h.broadcast <- msg:
+	default:
+	}
+}
+
+// join 将客户端加入 Hub，如果 Hub 已在升级完成前被销毁（比如房间刚好被
+// destroyRoomHandler 或 GC 回收），返回 false 让调用方拒绝这条连接。
+func (h *Hub) join(c *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	h.broadcastLocked(Message{Type: MsgJoin, RoomID: h.roomID, UserID: c.userID})
+	return true
+}
+
+func (h *Hub) leave(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+	}
+	h.broadcastLocked(Message{Type: MsgLeave, RoomID: h.roomID, UserID: c.userID})
+}
+
+// roomWSHandler 处理 /room/ws?room_id=...&userid=... 的升级请求，
+// 将调用方加入对应房间的 Hub，开始读写泵循环。
+func roomWSHandler(w http.ResponseWriter, r *http.Request) {
+	roomID := r.URL.Query().Get("room_id")
+	userID := r.URL.Query().Get("userid")
+	if roomID == "" || userID == "" {
+		http.Error(w, "room_id and userid are required", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := store.Exists(r.Context(), roomID)
+	if err != nil {
+		http.Error(w, "failed to query room", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	hub := hubManager.getOrCreate(roomID)
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("ws upgrade failed: %v", err)
+		return
+	}
+
+	client := &Client{
+		hub:    hub,
+		roomID: roomID,
+		userID: userID,
+		conn:   conn,
+		send:   make(chan Message, 16),
+	}
+	if !hub.join(client) {
+		// 房间在 upgrade 握手期间被销毁（destroyRoomHandler 或 GC），拒绝这条连接。
+		conn.Close()
+		return
+	}
+	// 有客户端真正加入说明房间仍在被使用，续一次活跃度，避免只有 WS 流量、
+	// 没有单独调用 /room/heartbeat 的房间被空闲 GC 误回收。
+	go touchRoomActivity(roomID)
+
+	go client.writePump()
+	go client.readPump()
+}
+
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.leave(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+
+	for {
+		var msg Message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			break
+		}
+		msg.RoomID = c.roomID
+		msg.UserID = c.userID
+		switch msg.Type {
+		case MsgChat, MsgDanmaku, MsgSignal:
+			c.hub.broadcastLocked(msg)
+			go touchRoomActivity(c.roomID)
+		default:
+			// 未知类型忽略，避免恶意/错误客户端影响其它人。
+		}
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+// TestHubJoinAfterShutdown 还原 roomWSHandler 里 upgrade 握手与房间销毁的竞态：
+// Hub 在 join 之前已经被 shutdown（destroyRoomHandler/GC 先完成），join 必须
+// 拒绝而不是向一个已置空的 clients map 写入导致 panic。
+func TestHubJoinAfterShutdown(t *testing.T) {
+	h := newHub("room-1")
+	h.shutdown()
+
+	c := &Client{hub: h, roomID: "room-1", userID: "u1", send: make(chan Message, 1)}
+	if ok := h.join(c); ok {
+		t.Fatalf("join on a shut-down hub returned true, want false")
+	}
+}
+
+// TestHubJoinThenShutdownThenDeliver 确认在 join 之后关闭 Hub，
+// 后续的 deliver/leave 不会对已清空的 clients 造成影响。
+func TestHubJoinThenShutdownThenDeliver(t *testing.T) {
+	h := newHub("room-1")
+	c := &Client{hub: h, roomID: "room-1", userID: "u1", send: make(chan Message, 1)}
+
+	if ok := h.join(c); !ok {
+		t.Fatalf("join on a live hub returned false, want true")
+	}
+
+	h.shutdown()
+
+	h.deliver(Message{Type: MsgChat, RoomID: "room-1"})
+
+	h.leave(c)
+}
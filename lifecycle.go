@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// gcSweepInterval 是空闲/过期房间清理 goroutine 的扫描周期。
+const gcSweepInterval = 3 * time.Second
+
+// defaultIdleTimeout 是未配置 ROOM_IDLE_TIMEOUT_SECONDS 时的默认空闲回收阈值。
+const defaultIdleTimeout = 30 * time.Minute
+
+// HeartbeatRequest 是 POST /room/heartbeat 的请求体。
+type HeartbeatRequest struct {
+	RoomID string `json:"room_id"`
+}
+
+// roomExpireHook 在房间因 TTL 或空闲超时被后台回收时调用，
+// 方便下游系统（如信令 Hub）感知房间销毁。
+type roomExpireHook func(roomID string)
+
+var roomExpireHooks = []roomExpireHook{hubManager.closeRoom}
+
+func fireRoomExpireHooks(roomID string) {
+	for _, h := range roomExpireHooks {
+		h(roomID)
+	}
+}
+
+func idleTimeout() time.Duration {
+	if v := os.Getenv("ROOM_IDLE_TIMEOUT_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return defaultIdleTimeout
+}
+
+// startRoomGC 启动后台 ticker，周期性扫描房间列表，回收 TTL 到期
+// 或空闲超过阈值的房间，模拟一个心跳驱动的房间存活状态机。
+func startRoomGC(ctx context.Context, store RoomStore) {
+	ticker := time.NewTicker(gcSweepInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sweepExpiredRooms(ctx, store)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func sweepExpiredRooms(ctx context.Context, store RoomStore) {
+	rooms, err := store.List(ctx)
+	if err != nil {
+		log.Printf("room gc: list failed: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	idle := int64(idleTimeout() / time.Second)
+
+	for _, room := range rooms {
+		expired := room.ExpireAt > 0 && now >= room.ExpireAt
+		idledOut := room.LastActiveAt > 0 && now-room.LastActiveAt >= idle
+
+		if !expired && !idledOut {
+			continue
+		}
+		if err := store.Delete(ctx, room.RoomID); err != nil {
+			log.Printf("room gc: failed to delete room %s: %v", room.RoomID, err)
+			continue
+		}
+		fireRoomExpireHooks(room.RoomID)
+		log.Printf("room gc: reclaimed room %s (expired=%v idle=%v)", room.RoomID, expired, idledOut)
+	}
+}
+
+// touchRoomActivity 刷新房间的 LastActiveAt，供信令 Hub 在有真实 WebSocket
+// 流量（join/chat/danmaku/signal）时调用，使空闲 GC 的判断不再只依赖
+// POST /room/heartbeat 这一条独立调用链——否则只靠 WS 通信、没有额外心跳
+// 调用方的房间会在 ROOM_IDLE_TIMEOUT_SECONDS 到期后被误回收。
+// 查询/更新失败时只记录日志：这是一次尽力而为的活跃度续期，不应影响调用方
+// 正在处理的信令收发。
+func touchRoomActivity(roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	room, ok, err := store.Get(ctx, roomID)
+	if err != nil || !ok {
+		return
+	}
+	room.LastActiveAt = time.Now().Unix()
+	if err := store.Update(ctx, room); err != nil {
+		log.Printf("touch room activity: failed to update room %s: %v", roomID, err)
+	}
+}
+
+// heartbeatHandler 处理 POST /room/heartbeat，刷新房间的 LastActiveAt，
+// 防止其被空闲 GC 回收。
+func heartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req HeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	room, ok, err := store.Get(r.Context(), req.RoomID)
+	if err != nil {
+		http.Error(w, "failed to query room", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+
+	room.LastActiveAt = time.Now().Unix()
+	if err := store.Update(r.Context(), room); err != nil {
+		http.Error(w, "failed to update room", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
+}
+
+// healthzHandler 处理 GET /healthz[?room_id=...]：不带 room_id 时仅做存活探测，
+// 带 room_id 时同时探测该房间是否存在，供上游 LB 做亲和路由。
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomID := r.URL.Query().Get("room_id")
+	if roomID == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	ok, err := store.Exists(r.Context(), roomID)
+	if err != nil {
+		http.Error(w, "failed to query room", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
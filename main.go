@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"math/rand"
 	"net/http"
-	"sort"
+	"os"
+	"os/signal"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 )
 
@@ -35,48 +37,59 @@ type Link struct {
 
 // Room 为返回给客户端的房间信息结构
 type Room struct {
-	RoomID      string   `json:"room_id"`
-	OwnerUserID string   `json:"owner_userid"`
-	CreateTime  int64    `json:"create_time"`
-	RoomType    RoomType `json:"room_type"`
-	RTCType     RTCType  `json:"rtc_type"`
+	RoomID       string   `json:"room_id"`
+	OwnerUserID  string   `json:"owner_userid"`
+	CreateTime   int64    `json:"create_time"`
+	RoomType     RoomType `json:"room_type"`
+	RTCType      RTCType  `json:"rtc_type"`
+	ExpireAt     int64    `json:"expire_at,omitempty"`      // 0 表示不设 TTL
+	LastActiveAt int64    `json:"last_active_at,omitempty"` // 由创建、心跳和 WS 活动刷新，供空闲 GC 判断回收
 }
 
 type CreateRoomRequest struct {
-	UserID   string `json:"userid"`
-	RoomType string `json:"room_type"` // Live 或 Audio
+	UserID     string `json:"userid"`
+	RoomType   string `json:"room_type"`             // Live 或 Audio
+	TTLSeconds int64  `json:"ttl_seconds,omitempty"` // 可选，房间存活时长；0 表示不设 TTL
 }
 
 type DestroyRoomRequest struct {
 	RoomID string `json:"room_id"`
 }
 
-var (
-	rooms   = make(map[string]Room)
-	roomsMu sync.RWMutex
-)
+// CreateRoomResponse 在房间信息基础上附带可直接交给客户端 SDK 使用的入会凭证。
+type CreateRoomResponse struct {
+	Room
+	Credential Credential `json:"credential"`
+}
+
+// store 是房间的持久化存取入口，默认走内存实现，
+// 可通过 STORE_DRIVER=redis|mongo 切换为可持久化、可横向扩展的后端。
+var store RoomStore
+
+// rtcRouter 负责在多个 RTC 后端之间做平滑加权路由，取代原先写死的 6:4 随机分配。
+var rtcRouter *RTCRouter
+
+const rtcProbeInterval = 10 * time.Second
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
+
+	s, err := NewRoomStore()
+	if err != nil {
+		log.Fatalf("store init failed: %v", err)
+	}
+	store = s
+
+	rtcRouter = NewRTCRouter()
+	if err := validateCredentialEnv(rtcRouter.Status()); err != nil {
+		log.Fatalf("%v", err)
+	}
+	rtcRouter.StartProbing(rtcProbeInterval)
 }
 
-// chooseRTC 根据比例来随机分配rtc房间类型
-// 规则 TRTC:Agora => 6:4
-// 0-5 => TRTC
-// 6-9 => Agora
+// chooseRTC 通过 rtcRouter 选出本次应当分配的 RTC 后端。
 func chooseRTC() RTCType {
-	// 生成的是 0-9 的随机数
-	u := rand.Intn(10)
-	// 使用 switch 对随机数进行判断：0-5 => TRTC，6-9 => Agora
-	switch u {
-	case 0, 1, 2, 3, 4, 5:
-		return RTC_TRTC
-	case 6, 7, 8, 9:
-		return RTC_AGORA
-	default:
-		// 按要求 userid 必定为数字字符，这里作为兜底返回 TRTC
-		return RTC_TRTC
-	}
+	return rtcRouter.Choose()
 }
 
 func generateRoomID(userid string) string {
@@ -103,21 +116,38 @@ func createRoomHandler(w http.ResponseWriter, r *http.Request) {
 
 	id := generateRoomID(req.UserID)
 	rtc := chooseRTC()
+	now := time.Now().Unix()
+
+	var expireAt int64
+	if req.TTLSeconds > 0 {
+		expireAt = now + req.TTLSeconds
+	}
 
 	room := Room{
-		RoomID:      id,
-		OwnerUserID: req.UserID,
-		CreateTime:  time.Now().Unix(),
-		RoomType:    rt,
-		RTCType:     rtc,
+		RoomID:       id,
+		OwnerUserID:  req.UserID,
+		CreateTime:   now,
+		RoomType:     rt,
+		RTCType:      rtc,
+		ExpireAt:     expireAt,
+		LastActiveAt: now,
+	}
+
+	// 先签发 join 凭证，凭证签发失败时房间还未落库，调用方不会拿到一个无法感知、
+	// 无法销毁的孤儿房间。
+	cred, err := issueCredential(rtc, room.RoomID, room.OwnerUserID)
+	if err != nil {
+		http.Error(w, "failed to issue credential", http.StatusInternalServerError)
+		return
 	}
 
-	roomsMu.Lock()
-	rooms[id] = room
-	roomsMu.Unlock()
+	if err := store.Create(r.Context(), room); err != nil {
+		http.Error(w, "failed to create room", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(room)
+	json.NewEncoder(w).Encode(CreateRoomResponse{Room: room, Credential: cred})
 }
 
 // destroyRoomHandler 解散房间接口
@@ -132,13 +162,20 @@ func destroyRoomHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roomsMu.Lock()
-	defer roomsMu.Unlock()
-	if _, ok := rooms[req.RoomID]; !ok {
+	ok, err := store.Exists(r.Context(), req.RoomID)
+	if err != nil {
+		http.Error(w, "failed to query room", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
 		http.Error(w, "room not found", http.StatusNotFound)
 		return
 	}
-	delete(rooms, req.RoomID)
+	if err := store.Delete(r.Context(), req.RoomID); err != nil {
+		http.Error(w, "failed to destroy room", http.StatusInternalServerError)
+		return
+	}
+	hubManager.closeRoom(req.RoomID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
 }
@@ -149,20 +186,12 @@ func listRoomsHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	roomsMu.RLock()
-	defer roomsMu.RUnlock()
-	list := make([]Room, 0, len(rooms))
-
-	for _, v := range rooms {
-
-		list = append(list, v)
+	list, err := store.List(r.Context())
+	if err != nil {
+		http.Error(w, "failed to list rooms", http.StatusInternalServerError)
+		return
 	}
 
-	// Sort by CreateTime in descending order
-	sort.Slice(list, func(i, j int) bool {
-		return list[i].CreateTime > list[j].CreateTime
-	})
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(list)
 }
@@ -172,14 +201,37 @@ func main() {
 	mux.HandleFunc("/room/create", createRoomHandler)
 	mux.HandleFunc("/room/destroy", destroyRoomHandler)
 	mux.HandleFunc("/room/list", listRoomsHandler)
+	mux.HandleFunc("/admin/rtc/weights", rtcWeightsHandler)
+	mux.HandleFunc("/admin/rtc/status", rtcStatusHandler)
+	mux.HandleFunc("/room/ws", roomWSHandler)
+	mux.HandleFunc("/room/refresh_token", refreshTokenHandler)
+	mux.HandleFunc("/room/heartbeat", heartbeatHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+
+	gcCtx, stopGC := context.WithCancel(context.Background())
+	startRoomGC(gcCtx, store)
+	defer stopGC()
 
 	srv := &http.Server{
 		Addr:    ":8376",
 		Handler: mux,
 	}
 
-	log.Printf("server listening on %s", srv.Addr)
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("server failed: %v", err)
+	go func() {
+		log.Printf("server listening on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server failed: %v", err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Printf("shutdown signal received, draining connections")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("graceful shutdown failed: %v", err)
 	}
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// RTCBackend 描述一个可选的 RTC 服务提供方及其路由权重。
+type RTCBackend struct {
+	Name            RTCType `json:"name"`
+	Weight          int     `json:"weight"` // 配置权重
+	effectiveWeight int     // 当前有效权重，健康探测失败时会被临时调低
+	currentWeight   int     // 平滑加权轮询算法的滚动状态
+	EffectiveWeight int     `json:"effective_weight"` // 仅由 Status() 填入 effectiveWeight 的快照
+	Healthy         bool    `json:"healthy"`
+	StatusURL       string  `json:"status_url,omitempty"`
+	AllocationCount int64   `json:"allocation_count"`
+}
+
+// RTCRouterConfig 是 RTCRouter 的可热加载配置。
+type RTCRouterConfig struct {
+	Backends []RTCBackendConfig `json:"backends"`
+}
+
+type RTCBackendConfig struct {
+	Name      RTCType `json:"name"`
+	Weight    int     `json:"weight"`
+	StatusURL string  `json:"status_url,omitempty"`
+}
+
+// RTCRouter 使用 Nginx 风格的平滑加权轮询在多个 RTC 后端之间分配房间，
+// 并通过后台探测goroutine跟踪每个后端的健康状态，替代原先 chooseRTC 里
+// 固定 6:4 比例的 rand.Intn(10) 随机分配。
+type RTCRouter struct {
+	mu       sync.Mutex
+	backends []*RTCBackend
+
+	probeInterval time.Duration
+	httpClient    *http.Client
+	stopCh        chan struct{}
+}
+
+// defaultRTCRouterConfig 复刻了原来硬编码的 TRTC:Agora = 6:4 比例，
+// 作为没有提供外部配置文件时的兜底配置。
+func defaultRTCRouterConfig() RTCRouterConfig {
+	return RTCRouterConfig{
+		Backends: []RTCBackendConfig{
+			{Name: RTC_TRTC, Weight: 6},
+			{Name: RTC_AGORA, Weight: 4},
+		},
+	}
+}
+
+// NewRTCRouter 从 RTC_ROUTER_CONFIG 指向的 JSON 文件加载配置；
+// 未设置或加载失败时回退到默认的 6:4 配置。
+func NewRTCRouter() *RTCRouter {
+	cfg := defaultRTCRouterConfig()
+	if path := os.Getenv("RTC_ROUTER_CONFIG"); path != "" {
+		if loaded, err := loadRTCRouterConfig(path); err == nil {
+			cfg = loaded
+		}
+	}
+
+	r := &RTCRouter{
+		httpClient: &http.Client{Timeout: 3 * time.Second},
+		stopCh:     make(chan struct{}),
+	}
+	r.applyConfig(cfg)
+	return r
+}
+
+func loadRTCRouterConfig(path string) (RTCRouterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RTCRouterConfig{}, err
+	}
+	var cfg RTCRouterConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return RTCRouterConfig{}, err
+	}
+	return cfg, nil
+}
+
+func (r *RTCRouter) applyConfig(cfg RTCRouterConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	backends := make([]*RTCBackend, 0, len(cfg.Backends))
+	for _, b := range cfg.Backends {
+		backends = append(backends, &RTCBackend{
+			Name:            b.Name,
+			Weight:          b.Weight,
+			effectiveWeight: b.Weight,
+			Healthy:         true,
+			StatusURL:       b.StatusURL,
+		})
+	}
+	r.backends = backends
+}
+
+// Reload 用新的权重配置替换当前路由表，供 POST /admin/rtc/weights 调用。
+func (r *RTCRouter) Reload(cfg RTCRouterConfig) {
+	r.applyConfig(cfg)
+}
+
+// Choose 按平滑加权轮询（SWRR）选出当前最合适的后端：
+// 每个后端的 currentWeight += effectiveWeight，选出最大的一个，
+// 再给它的 currentWeight 减去所有后端 effectiveWeight 之和。
+func (r *RTCRouter) Choose() RTCType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int
+	var best *RTCBackend
+	for _, b := range r.backends {
+		if !b.Healthy {
+			continue
+		}
+		b.currentWeight += b.effectiveWeight
+		total += b.effectiveWeight
+		if best == nil || b.currentWeight > best.currentWeight {
+			best = b
+		}
+	}
+
+	if best == nil {
+		// 所有后端都不健康，作为兜底仍然返回第一个配置的后端。
+		if len(r.backends) > 0 {
+			return r.backends[0].Name
+		}
+		return RTC_TRTC
+	}
+
+	best.currentWeight -= total
+	best.AllocationCount++
+	return best.Name
+}
+
+// Status 返回每个后端当前的有效权重、健康状况和分配计数，供 GET /admin/rtc/status 使用。
+func (r *RTCRouter) Status() []RTCBackend {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]RTCBackend, 0, len(r.backends))
+	for _, b := range r.backends {
+		cp := *b
+		cp.EffectiveWeight = b.effectiveWeight
+		out = append(out, cp)
+	}
+	return out
+}
+
+// StartProbing 启动后台 goroutine，按 interval 周期性探测每个后端的 StatusURL。
+// 探测失败的后端被标记为不健康并跳过路由，恢复后权重自动还原。
+func (r *RTCRouter) StartProbing(interval time.Duration) {
+	r.probeInterval = interval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.probeOnce()
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (r *RTCRouter) probeOnce() {
+	r.mu.Lock()
+	backends := make([]*RTCBackend, len(r.backends))
+	copy(backends, r.backends)
+	r.mu.Unlock()
+
+	for _, b := range backends {
+		healthy := r.probeBackend(b)
+
+		r.mu.Lock()
+		wasHealthy := b.Healthy
+		b.Healthy = healthy
+		if healthy && !wasHealthy {
+			b.effectiveWeight = b.Weight
+		}
+		if !healthy {
+			b.effectiveWeight = 0
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *RTCRouter) probeBackend(b *RTCBackend) bool {
+	if b.StatusURL == "" {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.httpClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.StatusURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// Stop 终止后台探测 goroutine。
+func (r *RTCRouter) Stop() {
+	close(r.stopCh)
+}
+
+// rtcWeightsHandler 处理 POST /admin/rtc/weights，以 JSON body 热加载新的权重配置。
+func rtcWeightsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var cfg RTCRouterConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(cfg.Backends) == 0 {
+		http.Error(w, "at least one backend is required", http.StatusBadRequest)
+		return
+	}
+	rtcRouter.Reload(cfg)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"result": "ok"})
+}
+
+// rtcStatusHandler 处理 GET /admin/rtc/status，返回当前各后端的权重、健康状态和分配计数。
+func rtcStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rtcRouter.Status())
+}
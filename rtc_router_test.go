@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+// TestRTCRouterChooseSWRRSequence 校验 Choose 对默认 6:4 配置产出的选择序列
+// 与 Nginx 平滑加权轮询算法的标准序列一致，且分配次数最终收敛到配置的权重比例。
+func TestRTCRouterChooseSWRRSequence(t *testing.T) {
+	r := &RTCRouter{}
+	r.applyConfig(defaultRTCRouterConfig())
+
+	want := []RTCType{
+		RTC_TRTC, RTC_AGORA, RTC_TRTC, RTC_AGORA, RTC_TRTC,
+		RTC_TRTC, RTC_AGORA, RTC_TRTC, RTC_AGORA, RTC_TRTC,
+	}
+
+	counts := map[RTCType]int{}
+	for i, w := range want {
+		got := r.Choose()
+		if got != w {
+			t.Fatalf("call %d: got %q, want %q", i+1, got, w)
+		}
+		counts[got]++
+	}
+
+	if counts[RTC_TRTC] != 6 || counts[RTC_AGORA] != 4 {
+		t.Fatalf("unexpected allocation counts over %d calls: %+v", len(want), counts)
+	}
+}
+
+// TestRTCRouterChooseSkipsUnhealthyBackend 确认被探测标记为不健康的后端
+// 不会被 Choose 选中，流量全部转移到剩余健康后端。
+func TestRTCRouterChooseSkipsUnhealthyBackend(t *testing.T) {
+	r := &RTCRouter{}
+	r.applyConfig(defaultRTCRouterConfig())
+
+	r.mu.Lock()
+	for _, b := range r.backends {
+		if b.Name == RTC_AGORA {
+			b.Healthy = false
+			b.effectiveWeight = 0
+		}
+	}
+	r.mu.Unlock()
+
+	for i := 0; i < 5; i++ {
+		if got := r.Choose(); got != RTC_TRTC {
+			t.Fatalf("call %d: got %q, want %q (only healthy backend)", i+1, got, RTC_TRTC)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RoomStore 抽象房间的持久化存取，使得房间状态可以脱离进程内存独立存在，
+// 从而支持多实例部署和重启不丢数据。
+type RoomStore interface {
+	Create(ctx context.Context, room Room) error
+	Get(ctx context.Context, roomID string) (Room, bool, error)
+	Update(ctx context.Context, room Room) error
+	Delete(ctx context.Context, roomID string) error
+	List(ctx context.Context) ([]Room, error)
+	Exists(ctx context.Context, roomID string) (bool, error)
+}
+
+// NewRoomStore 根据 STORE_DRIVER 环境变量选择具体的 RoomStore 实现。
+// 取值为 memory（默认）、redis 或 mongo。
+func NewRoomStore() (RoomStore, error) {
+	driver := os.Getenv("STORE_DRIVER")
+	switch driver {
+	case "", "memory":
+		return NewMemoryRoomStore(), nil
+	case "redis":
+		return NewRedisRoomStore(os.Getenv("REDIS_ADDR"), os.Getenv("REDIS_PASSWORD"))
+	case "mongo":
+		return NewMongoRoomStore(context.Background(), os.Getenv("MONGO_URI"), os.Getenv("MONGO_DATABASE"))
+	default:
+		return nil, fmt.Errorf("store: unknown STORE_DRIVER %q", driver)
+	}
+}
+
+// MemoryRoomStore 是进程内存中的房间存储，等价于原先的 rooms map 实现。
+type MemoryRoomStore struct {
+	mu    sync.RWMutex
+	rooms map[string]Room
+}
+
+func NewMemoryRoomStore() *MemoryRoomStore {
+	return &MemoryRoomStore{rooms: make(map[string]Room)}
+}
+
+func (s *MemoryRoomStore) Create(ctx context.Context, room Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms[room.RoomID] = room
+	return nil
+}
+
+func (s *MemoryRoomStore) Get(ctx context.Context, roomID string) (Room, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	room, ok := s.rooms[roomID]
+	return room, ok, nil
+}
+
+// Update overwrites an existing room's fields, e.g. to persist a heartbeat.
+func (s *MemoryRoomStore) Update(ctx context.Context, room Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms[room.RoomID] = room
+	return nil
+}
+
+func (s *MemoryRoomStore) Delete(ctx context.Context, roomID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rooms, roomID)
+	return nil
+}
+
+func (s *MemoryRoomStore) List(ctx context.Context) ([]Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Room, 0, len(s.rooms))
+	for _, v := range s.rooms {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreateTime > list[j].CreateTime
+	})
+	return list, nil
+}
+
+func (s *MemoryRoomStore) Exists(ctx context.Context, roomID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.rooms[roomID]
+	return ok, nil
+}
+
+// RedisRoomStore 使用 `rtc:room:{id}` 哈希存储单个房间字段，
+// 并用 `rtc:rooms:by_ctime` 有序集合（score 为 CreateTime）支持 List。
+type RedisRoomStore struct {
+	cli *redis.Client
+}
+
+const (
+	redisRoomKeyPrefix = "rtc:room:"
+	redisRoomIndexKey  = "rtc:rooms:by_ctime"
+)
+
+func NewRedisRoomStore(addr, password string) (*RedisRoomStore, error) {
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	cli := redis.NewClient(&redis.Options{Addr: addr, Password: password})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cli.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("store: redis ping: %w", err)
+	}
+	return &RedisRoomStore{cli: cli}, nil
+}
+
+func redisRoomKey(roomID string) string {
+	return redisRoomKeyPrefix + roomID
+}
+
+func (s *RedisRoomStore) Create(ctx context.Context, room Room) error {
+	pipe := s.cli.TxPipeline()
+	pipe.HSet(ctx, redisRoomKey(room.RoomID), map[string]interface{}{
+		"room_id":        room.RoomID,
+		"owner_userid":   room.OwnerUserID,
+		"create_time":    room.CreateTime,
+		"room_type":      string(room.RoomType),
+		"rtc_type":       string(room.RTCType),
+		"expire_at":      room.ExpireAt,
+		"last_active_at": room.LastActiveAt,
+	})
+	pipe.ZAdd(ctx, redisRoomIndexKey, redis.Z{Score: float64(room.CreateTime), Member: room.RoomID})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisRoomStore) Get(ctx context.Context, roomID string) (Room, bool, error) {
+	res, err := s.cli.HGetAll(ctx, redisRoomKey(roomID)).Result()
+	if err != nil {
+		return Room{}, false, err
+	}
+	if len(res) == 0 {
+		return Room{}, false, nil
+	}
+	return roomFromRedisHash(res), true, nil
+}
+
+// Update overwrites an existing room's hash fields, e.g. to persist a heartbeat.
+func (s *RedisRoomStore) Update(ctx context.Context, room Room) error {
+	return s.Create(ctx, room)
+}
+
+func (s *RedisRoomStore) Delete(ctx context.Context, roomID string) error {
+	pipe := s.cli.TxPipeline()
+	pipe.Del(ctx, redisRoomKey(roomID))
+	pipe.ZRem(ctx, redisRoomIndexKey, roomID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisRoomStore) List(ctx context.Context) ([]Room, error) {
+	ids, err := s.cli.ZRevRange(ctx, redisRoomIndexKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Room, 0, len(ids))
+	for _, id := range ids {
+		res, err := s.cli.HGetAll(ctx, redisRoomKey(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == 0 {
+			continue
+		}
+		list = append(list, roomFromRedisHash(res))
+	}
+	return list, nil
+}
+
+func (s *RedisRoomStore) Exists(ctx context.Context, roomID string) (bool, error) {
+	n, err := s.cli.Exists(ctx, redisRoomKey(roomID)).Result()
+	return n > 0, err
+}
+
+func roomFromRedisHash(h map[string]string) Room {
+	var createTime, expireAt, lastActiveAt int64
+	fmt.Sscanf(h["create_time"], "%d", &createTime)
+	fmt.Sscanf(h["expire_at"], "%d", &expireAt)
+	fmt.Sscanf(h["last_active_at"], "%d", &lastActiveAt)
+	return Room{
+		RoomID:       h["room_id"],
+		OwnerUserID:  h["owner_userid"],
+		CreateTime:   createTime,
+		RoomType:     RoomType(h["room_type"]),
+		RTCType:      RTCType(h["rtc_type"]),
+		ExpireAt:     expireAt,
+		LastActiveAt: lastActiveAt,
+	}
+}
+
+// MongoRoomStore 使用 `rooms` 集合存储房间文档，并在 room_id 上建立唯一索引。
+type MongoRoomStore struct {
+	coll *mongo.Collection
+}
+
+type mongoRoomDoc struct {
+	RoomID       string   `bson:"room_id"`
+	OwnerUserID  string   `bson:"owner_userid"`
+	CreateTime   int64    `bson:"create_time"`
+	RoomType     RoomType `bson:"room_type"`
+	RTCType      RTCType  `bson:"rtc_type"`
+	ExpireAt     int64    `bson:"expire_at"`
+	LastActiveAt int64    `bson:"last_active_at"`
+}
+
+func NewMongoRoomStore(ctx context.Context, uri, database string) (*MongoRoomStore, error) {
+	if uri == "" {
+		uri = "mongodb://127.0.0.1:27017"
+	}
+	if database == "" {
+		database = "rtcswitch"
+	}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("store: mongo connect: %w", err)
+	}
+	coll := client.Database(database).Collection("rooms")
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "room_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store: mongo index: %w", err)
+	}
+	return &MongoRoomStore{coll: coll}, nil
+}
+
+func (s *MongoRoomStore) Create(ctx context.Context, room Room) error {
+	_, err := s.coll.InsertOne(ctx, mongoRoomDocFromRoom(room))
+	return err
+}
+
+// Update replaces an existing room document in place (upserting if it somehow
+// doesn't exist yet), e.g. to persist a heartbeat without tripping the
+// unique index on room_id that InsertOne enforces.
+func (s *MongoRoomStore) Update(ctx context.Context, room Room) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"room_id": room.RoomID}, mongoRoomDocFromRoom(room), options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoRoomStore) Get(ctx context.Context, roomID string) (Room, bool, error) {
+	var doc mongoRoomDoc
+	err := s.coll.FindOne(ctx, bson.M{"room_id": roomID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return Room{}, false, nil
+	}
+	if err != nil {
+		return Room{}, false, err
+	}
+	return roomFromMongoDoc(doc), true, nil
+}
+
+func (s *MongoRoomStore) Delete(ctx context.Context, roomID string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"room_id": roomID})
+	return err
+}
+
+func (s *MongoRoomStore) List(ctx context.Context) ([]Room, error) {
+	cur, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "create_time", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	list := make([]Room, 0)
+	for cur.Next(ctx) {
+		var doc mongoRoomDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		list = append(list, roomFromMongoDoc(doc))
+	}
+	return list, cur.Err()
+}
+
+func (s *MongoRoomStore) Exists(ctx context.Context, roomID string) (bool, error) {
+	n, err := s.coll.CountDocuments(ctx, bson.M{"room_id": roomID})
+	return n > 0, err
+}
+
+func roomFromMongoDoc(doc mongoRoomDoc) Room {
+	return Room{
+		RoomID:       doc.RoomID,
+		OwnerUserID:  doc.OwnerUserID,
+		CreateTime:   doc.CreateTime,
+		RoomType:     doc.RoomType,
+		RTCType:      doc.RTCType,
+		ExpireAt:     doc.ExpireAt,
+		LastActiveAt: doc.LastActiveAt,
+	}
+}
+
+func mongoRoomDocFromRoom(room Room) mongoRoomDoc {
+	return mongoRoomDoc{
+		RoomID:       room.RoomID,
+		OwnerUserID:  room.OwnerUserID,
+		CreateTime:   room.CreateTime,
+		RoomType:     room.RoomType,
+		RTCType:      room.RTCType,
+		ExpireAt:     room.ExpireAt,
+		LastActiveAt: room.LastActiveAt,
+	}
+}